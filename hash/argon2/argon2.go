@@ -0,0 +1,248 @@
+// Package argon2 registers an Argon2 HashImplementation with the hash
+// package, supporting both the legacy go-hash encoding and the PHC string
+// format.
+package argon2
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	goargon2 "golang.org/x/crypto/argon2"
+
+	"github.com/HazCod/go-hash/hash"
+)
+
+const (
+	hashID                   = "argon2"
+	argonVersion             = 19
+	argonNumParameters       = 3
+	argonDefaultMemoryPasses = 4
+	argonDefaultMemorySize   = 64 * 1024
+	argonDefaultHashSize     = 32
+	argonDefaultMode         = "id"
+)
+
+var (
+	argonThreads = clampThreads(runtime.NumCPU() / 2) // half the cores, at least 1
+	argonModi    = []string{"i", "id"}                // argon modus
+
+	errBadParameters  = errors.New("malformed argon2 parameters")
+	errUnknownHashMod = errors.New("unknown argon2 modus")
+)
+
+// Argon2 is the default HashImplementation, backed by golang.org/x/crypto/argon2.
+type Argon2 struct {
+	MemoryPasses uint32 // time setting
+	MemorySize   uint32 // memory setting in KiB, e.g. 64*1024 -> 64MB
+	Mode         string // modus for argon, i or id
+	HashSize     uint32 // hash size in bytes (min. 16)
+	Threads      uint8  // parallelism (the PHC "p" parameter)
+}
+
+func init() {
+	hash.Register(&Argon2{
+		MemoryPasses: argonDefaultMemoryPasses,
+		MemorySize:   argonDefaultMemorySize,
+		Mode:         argonDefaultMode,
+		HashSize:     argonDefaultHashSize,
+		Threads:      argonThreads,
+	})
+}
+
+// clampThreads floors the parallelism degree at 1, since runtime.NumCPU()/2
+// is 0 on any single-vCPU host (common for containers/CI runners) and
+// golang.org/x/crypto/argon2 panics with threads=0.
+func clampThreads(threads int) uint8 {
+	if threads < 1 {
+		threads = 1
+	}
+
+	return uint8(threads)
+}
+
+func inStrArray(val string, array []string) bool {
+	for _, item := range array {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Argon2) encodedString() string {
+	return fmt.Sprintf("%s:%d:%d", a.Mode, a.MemoryPasses, a.MemorySize)
+}
+
+func (a *Argon2) Hash(password, salt []byte) (string, []byte, error) {
+	var key []byte
+
+	switch a.Mode {
+	case "i":
+		key = goargon2.Key(password, salt, a.MemoryPasses, a.MemorySize, a.Threads, a.HashSize)
+	case "id":
+		key = goargon2.IDKey(password, salt, a.MemoryPasses, a.MemorySize, a.Threads, a.HashSize)
+	default:
+		return "", nil, errUnknownHashMod
+	}
+
+	return a.encodedString(), key, nil
+}
+
+func (a *Argon2) Configure(parameters string, separator string, hashSize uint32) (hash.HashImplementation, error) {
+	pars := strings.Split(parameters, separator)
+
+	if len(pars) < argonNumParameters {
+		return nil, errBadParameters
+	}
+
+	passes, err := strconv.ParseUint(pars[1], 10, 32)
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	memory, err := strconv.ParseUint(pars[2], 10, 32)
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	return a.configureArgon(pars[0], hashSize, uint32(passes), uint32(memory))
+}
+
+func (a *Argon2) configureArgon(mode string, hashSize uint32, passes uint32, memory uint32) (hash.HashImplementation, error) {
+	nc := *a
+
+	if !inStrArray(mode, argonModi) || hashSize <= 0 || passes <= 0 || memory <= 0 {
+		return nil, errBadParameters
+	}
+
+	nc.Mode = mode
+	nc.HashSize = hashSize
+	nc.MemoryPasses = passes
+	nc.MemorySize = memory
+
+	return &nc, nil
+}
+
+func (a *Argon2) String() string {
+	return fmt.Sprintf("algo:%s mode:%s passes:%d memory:%d", hashID, a.Mode, a.MemoryPasses, a.MemorySize)
+}
+
+func (a *Argon2) GetID() string {
+	return hashID
+}
+
+func (a *Argon2) GetMode() string {
+	return a.Mode
+}
+
+func (a *Argon2) GetDefaultLength() int {
+	return argonDefaultHashSize
+}
+
+func (a *Argon2) GetNumParameters() int {
+	return argonNumParameters
+}
+
+func (a *Argon2) GetDefaultHashSize() int {
+	return argonDefaultHashSize
+}
+
+// Version returns the Argon2 reference version these hashes are produced
+// against, recorded in PHC-encoded hashes as "v=19".
+func (a *Argon2) Version() int {
+	return argonVersion
+}
+
+// Parameters returns the m/t/p triple EncodePHC writes into the PHC
+// parameter field.
+func (a *Argon2) Parameters() map[string]uint32 {
+	return map[string]uint32{
+		"m": a.MemorySize,
+		"t": a.MemoryPasses,
+		"p": uint32(a.Threads),
+	}
+}
+
+// PHCID returns the mode-qualified algorithm token EncodePHC writes in place
+// of GetID(), e.g. "argon2id", so the PHC string is self-describing and a
+// hash made in "i" mode isn't re-verified as "id".
+func (a *Argon2) PHCID() string {
+	return hashID + a.Mode
+}
+
+// PHCAliases lists the mode-qualified tokens PHCID can produce, so Register
+// indexes this implementation under "argon2i" and "argon2id" as well as the
+// bare "argon2" id used by the legacy format.
+func (a *Argon2) PHCAliases() []string {
+	aliases := make([]string, 0, len(argonModi))
+	for _, mode := range argonModi {
+		aliases = append(aliases, hashID+mode)
+	}
+
+	return aliases
+}
+
+// MarshalPHC encodes salt and key as an Argon2 PHC string, e.g.
+// $argon2id$v=19$m=65536,t=4,p=2$<salt>$<key>.
+func (a *Argon2) MarshalPHC(salt, key []byte) string {
+	return hash.EncodePHC(a, a.Parameters(), salt, key)
+}
+
+// UnmarshalPHC parses an Argon2 PHC string produced by MarshalPHC, returning
+// an implementation configured to reproduce it plus the decoded salt and key.
+func (a *Argon2) UnmarshalPHC(encoded string) (hash.HashImplementation, []byte, []byte, error) {
+	algo, version, params, salt, key, err := hash.ParsePHC(encoded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	mode := strings.TrimPrefix(algo, hashID)
+	if mode == algo || !inStrArray(mode, argonModi) {
+		return nil, nil, nil, errUnknownHashMod
+	}
+
+	if version != argonVersion {
+		return nil, nil, nil, errBadParameters
+	}
+
+	nc := *a
+	nc.Mode = mode
+	nc.HashSize = uint32(len(key))
+
+	for _, pair := range strings.Split(params, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, nil, nil, errBadParameters
+		}
+
+		v, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return nil, nil, nil, errBadParameters
+		}
+
+		switch kv[0] {
+		case "m":
+			nc.MemorySize = uint32(v)
+		case "t":
+			nc.MemoryPasses = uint32(v)
+		case "p":
+			// Argon2's output depends on p, so the stored value must be
+			// reapplied rather than falling back to this process's
+			// argonThreads, or a hash made on an N-core host fails to
+			// verify on a host with a different core count.
+			if v == 0 || v > 255 {
+				return nil, nil, nil, errBadParameters
+			}
+			nc.Threads = uint8(v)
+		case "k":
+			// pepper keyid; applied by the hash package, not reconfigured here.
+		default:
+			return nil, nil, nil, errBadParameters
+		}
+	}
+
+	return &nc, salt, key, nil
+}
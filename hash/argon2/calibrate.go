@@ -0,0 +1,131 @@
+package argon2
+
+import (
+	"errors"
+	"time"
+
+	"github.com/HazCod/go-hash/hash"
+)
+
+const (
+	owaspMinMemorySize   = 19 * 1024 // 19 MiB, OWASP minimum
+	owaspMinMemoryPasses = 2
+
+	// maxMemoryPasses bounds the pass-increment loop in Calibrate so an
+	// unreachable target (the box simply can't get there) returns the best
+	// effort instead of spinning, each iteration costing a full KDF run.
+	maxMemoryPasses = 64
+
+	// calibrateBaselinePasses is Calibrate's own OWASP-recommended starting
+	// point (t=3). It's deliberately distinct from argonDefaultMemoryPasses
+	// (t=4), which tunes the package's interactive-login default rather
+	// than calibration's starting point.
+	calibrateBaselinePasses = 3
+)
+
+var errMemoryCapTooLow = errors.New("memoryCap is below the OWASP minimum memory size")
+
+var calibrationPassword = []byte("go-hash-calibration-password")
+
+// Calibrate tunes MemorySize and MemoryPasses so a single Hash call takes
+// approximately target on the current hardware, without exceeding memoryCap
+// KiB. It starts from the OWASP-recommended baseline (m=64MiB, t=3,
+// p=NumCPU/2), times a hash of a fixed dummy password and salt, doubles
+// MemorySize until the elapsed time reaches target or memory hits memoryCap,
+// then increases MemoryPasses linearly, up to maxMemoryPasses, to close any
+// remaining gap; an unreachable target returns the best effort rather than
+// spinning forever. The result never falls below the OWASP minimums
+// (m>=19MiB, t>=2); memoryCap itself must be at least owaspMinMemorySize, or
+// Calibrate errors rather than silently clamping MemorySize below that floor.
+func Calibrate(target time.Duration, memoryCap uint32) (*Argon2, time.Duration, error) {
+	return calibrateFrom(target, memoryCap, calibrateBaselinePasses, argonDefaultMemorySize)
+}
+
+// CalibrateParameters lets hash.Calibrate drive calibration through the
+// default-registered implementation without the hash package importing
+// argon2 directly. It seeds the search from a's own MemoryPasses/MemorySize
+// rather than Calibrate's fixed baseline, so calibrating an already-tuned
+// default (e.g. hand-configured to t=4) never hands back fewer passes, such
+// as a weaker t=3 baseline just because target was already met there on fast
+// hardware. MemorySize is lowered only where it exceeds memoryCap.
+func (a *Argon2) CalibrateParameters(target time.Duration, memoryCap uint32) (hash.HashImplementation, time.Duration, error) {
+	basePasses := a.MemoryPasses
+	if basePasses < calibrateBaselinePasses {
+		basePasses = calibrateBaselinePasses
+	}
+
+	baseMemory := a.MemorySize
+	if baseMemory < argonDefaultMemorySize {
+		baseMemory = argonDefaultMemorySize
+	}
+
+	tuned, elapsed, err := calibrateFrom(target, memoryCap, basePasses, baseMemory)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tuned, elapsed, nil
+}
+
+// calibrateFrom implements Calibrate, starting the search from
+// basePasses/baseMemory rather than always assuming the package's own
+// baseline, so CalibrateParameters can seed it from an already-configured
+// implementation's parameters.
+func calibrateFrom(target time.Duration, memoryCap uint32, basePasses uint32, baseMemory uint32) (*Argon2, time.Duration, error) {
+	if memoryCap < owaspMinMemorySize {
+		return nil, 0, errMemoryCapTooLow
+	}
+
+	a := &Argon2{
+		Mode:         argonDefaultMode,
+		MemoryPasses: basePasses,
+		MemorySize:   baseMemory,
+		HashSize:     argonDefaultHashSize,
+		Threads:      argonThreads,
+	}
+
+	if a.MemorySize < owaspMinMemorySize {
+		a.MemorySize = owaspMinMemorySize
+	}
+	if a.MemoryPasses < owaspMinMemoryPasses {
+		a.MemoryPasses = owaspMinMemoryPasses
+	}
+	if a.MemorySize > memoryCap {
+		a.MemorySize = memoryCap
+	}
+
+	salt := make([]byte, 16)
+
+	elapsed, err := a.timeHash(salt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for elapsed < target && a.MemorySize*2 <= memoryCap {
+		a.MemorySize *= 2
+
+		if elapsed, err = a.timeHash(salt); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	for elapsed < target && a.MemoryPasses < maxMemoryPasses {
+		a.MemoryPasses++
+
+		if elapsed, err = a.timeHash(salt); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return a, elapsed, nil
+}
+
+func (a *Argon2) timeHash(salt []byte) (time.Duration, error) {
+	start := time.Now()
+
+	if _, _, err := a.Hash(calibrationPassword, salt); err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
@@ -0,0 +1,154 @@
+// Package bcrypt registers a bcrypt HashImplementation with the hash package.
+package bcrypt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gobcrypt "golang.org/x/crypto/bcrypt"
+
+	"github.com/HazCod/go-hash/hash"
+)
+
+const (
+	hashID              = "bcrypt"
+	bcryptNumParameters = 1
+	bcryptDefaultCost   = gobcrypt.DefaultCost
+	// bcryptDefaultHashSize is the length, in bytes, of a standard bcrypt
+	// modular crypt string (e.g. "$2a$10$...").
+	bcryptDefaultHashSize = 60
+	// bcryptVersion is the major bcrypt revision these hashes are produced
+	// against (golang.org/x/crypto/bcrypt emits "$2a$" hashes).
+	bcryptVersion = 2
+)
+
+var errBadParameters = errors.New("malformed bcrypt parameters")
+
+// Bcrypt is a HashImplementation backed by golang.org/x/crypto/bcrypt. It
+// ignores the salt passed to Hash, since bcrypt generates and embeds its own.
+type Bcrypt struct {
+	Cost int
+}
+
+func init() {
+	hash.Register(&Bcrypt{Cost: bcryptDefaultCost})
+}
+
+func (b *Bcrypt) Hash(password, _ []byte) (string, []byte, error) {
+	encoded, err := gobcrypt.GenerateFromPassword(password, b.Cost)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strconv.Itoa(b.Cost), encoded, nil
+}
+
+// VerifyKey reports whether password matches key, a full bcrypt modular
+// crypt string as returned by Hash. bcrypt embeds and ignores its own
+// random salt, so recomputing Hash(password, salt) never reproduces the
+// same bytes even for the correct password; this is why Bcrypt implements
+// selfSalting instead of relying on the package's default recompute path.
+func (b *Bcrypt) VerifyKey(password, key []byte) (bool, error) {
+	err := gobcrypt.CompareHashAndPassword(key, password)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gobcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (b *Bcrypt) Configure(parameters string, separator string, _ uint32) (hash.HashImplementation, error) {
+	pars := strings.Split(parameters, separator)
+
+	cost, err := strconv.Atoi(pars[0])
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	nc := *b
+	nc.Cost = cost
+
+	return &nc, nil
+}
+
+func (b *Bcrypt) GetID() string { return hashID }
+
+func (b *Bcrypt) GetDefaultLength() int { return bcryptDefaultHashSize }
+
+func (b *Bcrypt) GetNumParameters() int { return bcryptNumParameters }
+
+func (b *Bcrypt) GetDefaultHashSize() int { return bcryptDefaultHashSize }
+
+func (b *Bcrypt) String() string {
+	return fmt.Sprintf("algo:%s cost:%d", hashID, b.Cost)
+}
+
+// Version returns the major bcrypt revision these hashes are produced
+// against (2, as in "$2a$").
+func (b *Bcrypt) Version() int { return bcryptVersion }
+
+func (b *Bcrypt) Parameters() map[string]uint32 {
+	return map[string]uint32{"cost": uint32(b.Cost)}
+}
+
+// PHCID returns the bare id; bcrypt has no mode variants to disambiguate.
+func (b *Bcrypt) PHCID() string { return hashID }
+
+// PHCAliases returns nil; bcrypt is only ever indexed under GetID().
+func (b *Bcrypt) PHCAliases() []string { return nil }
+
+// MarshalPHC encodes key (a full bcrypt modular crypt string) as a PHC
+// string, e.g. $bcrypt$v=2$cost=10$<salt>$<key>. salt is carried only for
+// format uniformity; bcrypt ignores it, since key already embeds its own.
+func (b *Bcrypt) MarshalPHC(salt, key []byte) string {
+	return hash.EncodePHC(b, b.Parameters(), salt, key)
+}
+
+// UnmarshalPHC parses a bcrypt PHC string produced by MarshalPHC, returning
+// an implementation configured to reproduce it plus the decoded salt and
+// key. The returned key is a full bcrypt modular crypt string, to be passed
+// to VerifyKey rather than recomputed against.
+func (b *Bcrypt) UnmarshalPHC(encoded string) (hash.HashImplementation, []byte, []byte, error) {
+	algo, version, params, salt, key, err := hash.ParsePHC(encoded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if algo != hashID {
+		return nil, nil, nil, errBadParameters
+	}
+
+	if version != bcryptVersion {
+		return nil, nil, nil, errBadParameters
+	}
+
+	nc := *b
+
+	for _, pair := range strings.Split(params, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, nil, nil, errBadParameters
+		}
+
+		switch kv[0] {
+		case "cost":
+			cost, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, nil, nil, errBadParameters
+			}
+			nc.Cost = cost
+		case "k":
+			// pepper keyid; not applicable to bcrypt (selfSalting skips
+			// peppering), tolerated here for forward-compatibility.
+		default:
+			return nil, nil, nil, errBadParameters
+		}
+	}
+
+	return &nc, salt, key, nil
+}
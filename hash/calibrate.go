@@ -0,0 +1,35 @@
+package hash
+
+import "time"
+
+// calibrator is implemented by HashImplementations whose cost parameters can
+// be tuned to hit a target duration, e.g. argon2.Argon2.
+type calibrator interface {
+	CalibrateParameters(target time.Duration, cap uint32) (HashImplementation, time.Duration, error)
+}
+
+// Calibrate tunes the current default algorithm's parameters so a single
+// Hash call takes approximately target, without exceeding cap (an
+// algorithm-specific cost ceiling, e.g. KiB of memory for Argon2). The tuned
+// implementation is registered as the new default configuration, and its
+// measured duration is returned.
+func Calibrate(target time.Duration, cap uint32) (time.Duration, error) {
+	impl, found := lookupImpl(currentDefault())
+	if !found {
+		return 0, errUnknownHashImpl
+	}
+
+	calib, ok := impl.(calibrator)
+	if !ok {
+		return 0, errUnknownHashImpl
+	}
+
+	tuned, elapsed, err := calib.CalibrateParameters(target, cap)
+	if err != nil {
+		return 0, err
+	}
+
+	Register(tuned)
+
+	return elapsed, nil
+}
@@ -19,6 +19,10 @@ const (
 	ParameterSeparator = ":"
 )
 
+// HashImplementations maps algorithm ids and PHC aliases to their registered
+// HashImplementation. Mutate it only through Register, and read it only
+// through lookupImpl/SetDefault's internal lookups; direct access bypasses
+// registryMu and races with concurrent Register/SetDefault calls.
 var (
 	HashImplementations = make(map[string]HashImplementation)
 
@@ -34,6 +38,20 @@ type HashImplementation interface {
 	GetNumParameters() (int)
 	String() (string)
 	GetDefaultHashSize() (int)
+	// Version returns the algorithm version recorded in PHC-encoded hashes,
+	// e.g. 19 for the current Argon2 spec.
+	Version() (int)
+	// Parameters returns the tunable parameters (e.g. m/t/p for Argon2) that
+	// EncodePHC writes into the PHC parameter field.
+	Parameters() (map[string]uint32)
+	// PHCID returns the self-describing algorithm token EncodePHC writes in
+	// place of GetID(), e.g. "argon2id" rather than the bare "argon2", so the
+	// PHC string round-trips the variant a bare id would otherwise lose.
+	PHCID() (string)
+	// PHCAliases lists additional PHC tokens, beyond GetID(), that Register
+	// also indexes this implementation under, so a PHCID() like "argon2id"
+	// can still be looked up by verifyPHC and NeedsRehash.
+	PHCAliases() ([]string)
 }
 
 
@@ -59,10 +77,28 @@ func hmacKey(params string, key []byte) ([]byte, error) {
 	return sum, nil
 }
 
-func Hash(input []byte) (string, error) {
+// Hash takes input as []byte, not string, so callers can zeroize their own
+// copy once Hash returns; Hash zeroizes every intermediate buffer it derives
+// from input before returning. If a pepper is set, opts' requested Format is
+// overridden to FormatPHC so the pepper keyid can be recorded.
+func Hash(input []byte, opts ...HashOptions) (string, error) {
 
-	var hashImpl = DefaultAlgo
-	var hasher = HashImplementations[hashImpl]
+	var opt HashOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	// The legacy format's HMAC key is the public parameter prefix, with no
+	// field to record a pepper keyid; peppering it in place would drop the
+	// params-tamper binding that prefix provides. Once a pepper is set,
+	// force PHC output, which already has a "k=" field for exactly this.
+	format := opt.Format
+	if _, peppered := currentPepperID(); peppered {
+		format = FormatPHC
+	}
+
+	var hashImpl = currentDefault()
+	hasher, _ := lookupImpl(hashImpl)
 
 	var salt = GenerateRandomBytes(SaltSize)
 
@@ -70,6 +106,24 @@ func Hash(input []byte) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	defer zeroize(hash)
+
+	_, selfSalts := hasher.(selfSalting)
+
+	if format == FormatPHC {
+		phcParams := hasher.Parameters()
+		keyToStore := hash
+
+		if !selfSalts {
+			if peppered, keyid, used := pepperKey(hash); used {
+				keyToStore = peppered
+				phcParams = withPepperID(phcParams, keyid)
+				defer zeroize(peppered)
+			}
+		}
+
+		return EncodePHC(hasher, phcParams, salt, keyToStore), nil
+	}
 
 	hashSize := byte(len(hash))
 	salt = append([]byte{hashSize}, salt...)
@@ -77,12 +131,23 @@ func Hash(input []byte) (string, error) {
 	encodedSalt := base64.StdEncoding.EncodeToString(salt)
 
 	prefix := fmt.Sprintf("$%v$%v$%v$", hashImpl, params, encodedSalt)
-	hmacHash, err := hmacKey(prefix, hash)
-	if err != nil {
-		return "", err
-	}
 
-	encodedHash := base64.StdEncoding.EncodeToString(hmacHash)
+	// Self-salting implementations (bcrypt) store their own key verbatim:
+	// hash already embeds everything CompareHashAndPassword needs, and
+	// wrapping it in an HMAC keyed on prefix would be unrecoverable, since
+	// hmacKey is one-way.
+	var encodedHash string
+	if selfSalts {
+		encodedHash = base64.StdEncoding.EncodeToString(hash)
+	} else {
+		hmacHash, err := hmacKey(prefix, hash)
+		if err != nil {
+			return "", err
+		}
+		defer zeroize(hmacHash)
+
+		encodedHash = base64.StdEncoding.EncodeToString(hmacHash)
+	}
 
 	return prefix + encodedHash, nil
 }
@@ -94,7 +159,7 @@ func parseHash(hash string) (HashImplementation, string, []byte, int, string, er
 		return nil, "", nil, 0, "", errBadHashFormat
 	}
 
-	hashImpl, found := HashImplementations[parts[1]]
+	hashImpl, found := lookupImpl(parts[1])
 	if ! found {
 		return nil, "", nil, 0, "", errUnknownHashImpl
 	}
@@ -104,6 +169,10 @@ func parseHash(hash string) (HashImplementation, string, []byte, int, string, er
 		return nil, "", nil, 0, "", errBadHashFormat
 	}
 
+	if len(salt) == 0 {
+		return nil, "", nil, 0, "", errBadHashFormat
+	}
+
 	hashSize := int(salt[0])
 	params := parts[2]
 	salt = salt[1:]
@@ -112,40 +181,183 @@ func parseHash(hash string) (HashImplementation, string, []byte, int, string, er
 	return hashImpl, params, salt, hashSize, key, nil
 }
 
+// constantTimeEqual reports whether a and b hold the same bytes, without
+// leaking timing information about where they first differ.
+func constantTimeEqual(a, b []byte) bool {
+	return hmac.Equal(a, b)
+}
+
+// zeroize overwrites b's contents with zero bytes, a best-effort defence
+// against derived key material lingering in memory after use.
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// dummyPassword is hashed on parse/configure failure paths so that
+// malformed input takes the same wall-clock time as a genuine mismatch,
+// rather than returning early and leaking which validation step failed.
+var dummyPassword = []byte("go-hash-constant-time-failure-path")
+
+// failConstantTime runs a dummy hash of the default algorithm and compares
+// it, in constant time, against a zero buffer before returning err. Callers
+// use it in place of an early "return false, err" so VerifyHash's total
+// wall-clock time is dominated by the KDF regardless of where validation
+// failed.
+func failConstantTime(err error) (bool, error) {
+	if impl, found := lookupImpl(currentDefault()); found {
+		if salt := GenerateRandomBytes(SaltSize); salt != nil {
+			if _, dummyKey, hashErr := impl.Hash(dummyPassword, salt); hashErr == nil {
+				constantTimeEqual(dummyKey, make([]byte, len(dummyKey)))
+				zeroize(dummyKey)
+			}
+		}
+	}
+
+	return false, err
+}
+
+// VerifyHash takes input as []byte, not string, so callers can zeroize
+// their own copy once VerifyHash returns. Any parse, configure or decode
+// failure falls through to failConstantTime rather than returning early, so
+// total wall-clock time is dominated by the KDF regardless of which part of
+// hash is malformed. Implementations satisfying selfSalting (bcrypt) are
+// checked via VerifyKey instead of the recompute-and-compare path below.
 func VerifyHash(hash string, input []byte) (bool, error) {
+	if isPHCEncoded(hash) {
+		return verifyPHC(hash, input)
+	}
+
 	hashImpl, paramStr, salt, hashSize, key, err := parseHash(hash)
 	if err != nil {
-		return false, err
+		return failConstantTime(err)
 	}
 
 	hashImpl, err = hashImpl.Configure(paramStr, ParameterSeparator, uint32(hashSize))
 	if err != nil {
-		return false, err
+		return failConstantTime(err)
+	}
+
+	if verifier, ok := hashImpl.(selfSalting); ok {
+		storedKey, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return failConstantTime(err)
+		}
+		defer zeroize(storedKey)
+
+		matched, err := verifier.VerifyKey(input, storedKey)
+		if err != nil {
+			return failConstantTime(err)
+		}
+
+		return matched, nil
 	}
 
 	_, otherKey, err := hashImpl.Hash(input, salt)
 	if err != nil {
-		return false, err
+		return failConstantTime(err)
 	}
+	defer zeroize(otherKey)
 
 	hashed, err := hmacKey(hash[:len(hash)-len(key)], otherKey)
 	if err != nil {
-		return false, err
+		return failConstantTime(err)
 	}
+	defer zeroize(hashed)
 
 	baseMac, err := base64.StdEncoding.DecodeString(key)
 	if err != nil {
-		return false, err
+		return failConstantTime(err)
+	}
+
+	return constantTimeEqual(baseMac, hashed), nil
+}
+
+// VerifyAndUpgrade verifies input against stored exactly as VerifyHash does,
+// then, on successful verification, consults NeedsRehash to see whether
+// stored falls behind PreferredOrder's current head (wrong algorithm,
+// outdated parameters, or a rotated-away pepper keyid). If so it hashes
+// input fresh under the current default and returns that as upgraded, in
+// the same format (PHC or legacy) stored was in, except that a legacy hash
+// comes back as PHC whenever a pepper is set, since Hash forces PHC then;
+// callers persist upgraded in place of stored. upgraded is empty when no
+// upgrade is due.
+func VerifyAndUpgrade(stored string, input []byte) (ok bool, upgraded string, err error) {
+	ok, err = VerifyHash(stored, input)
+	if err != nil || !ok {
+		return ok, "", err
 	}
 
-	return hmac.Equal(baseMac, hashed), nil
+	needsRehash, err := NeedsRehash(stored)
+	if err != nil || !needsRehash {
+		return ok, "", nil
+	}
+
+	format := FormatLegacy
+	if isPHCEncoded(stored) {
+		format = FormatPHC
+	}
+
+	upgraded, err = Hash(input, HashOptions{Format: format})
+	if err != nil {
+		return ok, "", err
+	}
+
+	return ok, upgraded, nil
 }
 
-func NeedsRehash(hash string) (bool, error) {
-	hashImpl, _, salt, hashSize, _, err := parseHash(hash)
+// NeedsRehash reports whether stored should be replaced with a fresh hash:
+// because it was produced by an algorithm other than the current default,
+// because its recorded parameters (memory/time/keylen) fall short of the
+// default's current settings, or because it was peppered under a key id
+// other than the keyring's current PreferredID. Parameters and pepper key
+// ids are compared for PHC strings only; a legacy hash under the default
+// algorithm is flagged only when its salt or key is shorter than the
+// defaults, and its memory/time parameters are not compared.
+func NeedsRehash(stored string) (bool, error) {
+	defaultImpl, found := lookupImpl(currentDefault())
+	if !found {
+		return false, errUnknownHashImpl
+	}
+
+	if isPHCEncoded(stored) {
+		algo, _, paramStr, _, _, err := ParsePHC(stored)
+		if err != nil {
+			return false, err
+		}
+
+		if !phcMatchesImpl(algo, defaultImpl) {
+			return true, nil
+		}
+
+		if paramsOutdated(defaultImpl.Parameters(), paramStr) {
+			return true, nil
+		}
+
+		// Hash never peppers a selfSalting implementation's output (see the
+		// selfSalts check there), so a bare algo/param match is already
+		// up to date regardless of the keyring's current pepper; comparing
+		// keyids here would otherwise demand a "k=" field Hash can never
+		// write, forcing a rehash on every call.
+		if _, selfSalts := defaultImpl.(selfSalting); selfSalts {
+			return false, nil
+		}
+
+		storedKeyID, storedHasPepper := pepperIDFromParams(paramStr)
+		wantKeyID, wantPepper := currentPepperID()
+
+		return storedHasPepper != wantPepper || (storedHasPepper && storedKeyID != wantKeyID), nil
+	}
+
+	hashImpl, _, salt, hashSize, _, err := parseHash(stored)
 	if err != nil {
 		return false, err
 	}
 
-	return hashImpl.GetID() != DefaultAlgo && len(salt) < SaltSize && hashSize < hashImpl.GetDefaultHashSize(), nil
+	if hashImpl.GetID() != defaultImpl.GetID() {
+		return true, nil
+	}
+
+	return len(salt) < SaltSize || hashSize < hashImpl.GetDefaultHashSize(), nil
 }
\ No newline at end of file
@@ -0,0 +1,199 @@
+// Package hash_test exercises the hash package's public API against every
+// registered backend. It lives in an external test package (rather than
+// hash_test.go inside package hash) because the backend packages import
+// hash for registration, and importing them back from inside package hash
+// would be a cycle.
+package hash_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HazCod/go-hash/hash"
+
+	_ "github.com/HazCod/go-hash/hash/argon2"
+	_ "github.com/HazCod/go-hash/hash/bcrypt"
+	_ "github.com/HazCod/go-hash/hash/pbkdf2"
+	_ "github.com/HazCod/go-hash/hash/scrypt"
+)
+
+// registeredAlgos lists every backend package imported above for its
+// registration side effect.
+var registeredAlgos = []string{"argon2", "bcrypt", "scrypt", "pbkdf2"}
+
+// TestRoundTripPHC hashes and verifies a password under every registered
+// backend via PHC encoding, and checks that a freshly produced hash does not
+// need a rehash.
+func TestRoundTripPHC(t *testing.T) {
+	for _, algo := range registeredAlgos {
+		t.Run(algo, func(t *testing.T) {
+			if err := hash.SetDefault(algo); err != nil {
+				t.Fatalf("SetDefault(%q): %v", algo, err)
+			}
+
+			password := []byte("correct horse battery staple")
+			encoded, err := hash.Hash(password, hash.HashOptions{Format: hash.FormatPHC})
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			ok, err := hash.VerifyHash(encoded, password)
+			if err != nil {
+				t.Fatalf("VerifyHash(correct password): %v", err)
+			}
+			if !ok {
+				t.Fatalf("VerifyHash(correct password) = false, want true")
+			}
+
+			ok, err = hash.VerifyHash(encoded, []byte("wrong password"))
+			if err != nil {
+				t.Fatalf("VerifyHash(wrong password): %v", err)
+			}
+			if ok {
+				t.Fatalf("VerifyHash(wrong password) = true, want false")
+			}
+
+			needsRehash, err := hash.NeedsRehash(encoded)
+			if err != nil {
+				t.Fatalf("NeedsRehash: %v", err)
+			}
+			if needsRehash {
+				t.Fatalf("NeedsRehash = true for a hash just produced under the current default")
+			}
+		})
+	}
+}
+
+// TestRoundTripLegacy covers the legacy format for every registered backend,
+// including bcrypt: its own embedded salt makes Hash's recompute-and-compare
+// scheme impossible, but VerifyHash's selfSalting branch dispatches to
+// VerifyKey instead, so the legacy format round-trips for bcrypt too.
+func TestRoundTripLegacy(t *testing.T) {
+	for _, algo := range registeredAlgos {
+		t.Run(algo, func(t *testing.T) {
+			if err := hash.SetDefault(algo); err != nil {
+				t.Fatalf("SetDefault(%q): %v", algo, err)
+			}
+
+			password := []byte("correct horse battery staple")
+			encoded, err := hash.Hash(password)
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			ok, err := hash.VerifyHash(encoded, password)
+			if err != nil || !ok {
+				t.Fatalf("VerifyHash(correct password) = (%v, %v), want (true, nil)", ok, err)
+			}
+
+			ok, err = hash.VerifyHash(encoded, []byte("wrong password"))
+			if err != nil || ok {
+				t.Fatalf("VerifyHash(wrong password) = (%v, %v), want (false, nil)", ok, err)
+			}
+		})
+	}
+}
+
+// TestCalibrate exercises hash.Calibrate end to end against the argon2
+// backend: a tuned implementation is registered as the new default, and a
+// hash produced under it must still verify.
+func TestCalibrate(t *testing.T) {
+	if err := hash.SetDefault("argon2"); err != nil {
+		t.Fatalf("SetDefault(argon2): %v", err)
+	}
+
+	elapsed, err := hash.Calibrate(5*time.Millisecond, 32*1024)
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+	if elapsed <= 0 {
+		t.Fatalf("Calibrate elapsed = %v, want > 0", elapsed)
+	}
+
+	password := []byte("correct horse battery staple")
+	encoded, err := hash.Hash(password, hash.HashOptions{Format: hash.FormatPHC})
+	if err != nil {
+		t.Fatalf("Hash after Calibrate: %v", err)
+	}
+
+	ok, err := hash.VerifyHash(encoded, password)
+	if err != nil || !ok {
+		t.Fatalf("VerifyHash after Calibrate = (%v, %v), want (true, nil)", ok, err)
+	}
+}
+
+// TestCalibrateMemoryCapTooLow checks Calibrate rejects a memoryCap below
+// the OWASP minimum rather than silently clamping MemorySize below it.
+func TestCalibrateMemoryCapTooLow(t *testing.T) {
+	if err := hash.SetDefault("argon2"); err != nil {
+		t.Fatalf("SetDefault(argon2): %v", err)
+	}
+
+	if _, err := hash.Calibrate(time.Millisecond, 1024); err == nil {
+		t.Fatalf("Calibrate with a below-OWASP-minimum memoryCap = nil error, want error")
+	}
+}
+
+// TestPepperSelfSaltingSettles covers the bcrypt + pepper combination:
+// bcrypt's own embedded salt means Hash never peppers its output (see the
+// selfSalts check in Hash), so a hash it just produced must not demand a
+// rehash on every subsequent VerifyAndUpgrade call.
+func TestPepperSelfSaltingSettles(t *testing.T) {
+	defer hash.SetPepper(nil)
+
+	if err := hash.SetDefault("bcrypt"); err != nil {
+		t.Fatalf("SetDefault(bcrypt): %v", err)
+	}
+	hash.SetPepper([]byte("pepper-secret"))
+
+	password := []byte("correct horse battery staple")
+	encoded, err := hash.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	ok, upgraded, err := hash.VerifyAndUpgrade(encoded, password)
+	if err != nil || !ok {
+		t.Fatalf("VerifyAndUpgrade = (%v, _, %v), want (true, _, nil)", ok, err)
+	}
+	if upgraded != "" {
+		t.Fatalf("VerifyAndUpgrade upgraded a hash just produced under the current default and pepper")
+	}
+}
+
+// TestPepperKeyRotationTriggersRehash covers a non-selfSalting backend: a
+// hash peppered under one keyid settles (NeedsRehash = false) until the
+// keyring's PreferredID rotates away from it, at which point it must be
+// flagged for rehash.
+func TestPepperKeyRotationTriggersRehash(t *testing.T) {
+	defer hash.SetPepper(nil)
+
+	if err := hash.SetDefault("argon2"); err != nil {
+		t.Fatalf("SetDefault(argon2): %v", err)
+	}
+	hash.SetPepperKeyring(&hash.PepperKeyring{
+		Keys:        map[uint32][]byte{1: []byte("old-pepper")},
+		PreferredID: 1,
+	})
+
+	password := []byte("correct horse battery staple")
+	encoded, err := hash.Hash(password, hash.HashOptions{Format: hash.FormatPHC})
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	needsRehash, err := hash.NeedsRehash(encoded)
+	if err != nil || needsRehash {
+		t.Fatalf("NeedsRehash before rotation = (%v, %v), want (false, nil)", needsRehash, err)
+	}
+
+	hash.SetPepperKeyring(&hash.PepperKeyring{
+		Keys:        map[uint32][]byte{1: []byte("old-pepper"), 2: []byte("new-pepper")},
+		PreferredID: 2,
+	})
+
+	needsRehash, err = hash.NeedsRehash(encoded)
+	if err != nil || !needsRehash {
+		t.Fatalf("NeedsRehash after rotation = (%v, %v), want (true, nil)", needsRehash, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package pbkdf2 registers a PBKDF2-HMAC-SHA256 HashImplementation with the
+// hash package.
+package pbkdf2
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gopbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/HazCod/go-hash/hash"
+)
+
+const (
+	hashID                  = "pbkdf2"
+	pbkdf2NumParameters     = 1
+	pbkdf2DefaultIterations = 600000 // OWASP-recommended minimum for PBKDF2-HMAC-SHA256
+	pbkdf2DefaultHashSize   = 32
+	// pbkdf2Version identifies the PRF generation these hashes are produced
+	// against (HMAC-SHA256).
+	pbkdf2Version = 2
+)
+
+var errBadParameters = errors.New("malformed pbkdf2 parameters")
+
+// PBKDF2 is a HashImplementation backed by golang.org/x/crypto/pbkdf2, using
+// HMAC-SHA256 as its pseudorandom function.
+type PBKDF2 struct {
+	Iterations int
+	HashSize   uint32
+}
+
+func init() {
+	hash.Register(&PBKDF2{Iterations: pbkdf2DefaultIterations, HashSize: pbkdf2DefaultHashSize})
+}
+
+func (p *PBKDF2) Hash(password, salt []byte) (string, []byte, error) {
+	key := gopbkdf2.Key(password, salt, p.Iterations, int(p.HashSize), sha256.New)
+
+	return strconv.Itoa(p.Iterations), key, nil
+}
+
+func (p *PBKDF2) Configure(parameters string, separator string, hashSize uint32) (hash.HashImplementation, error) {
+	pars := strings.Split(parameters, separator)
+
+	iterations, err := strconv.Atoi(pars[0])
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	nc := *p
+	nc.Iterations = iterations
+	nc.HashSize = hashSize
+
+	return &nc, nil
+}
+
+func (p *PBKDF2) GetID() string { return hashID }
+
+func (p *PBKDF2) GetDefaultLength() int { return pbkdf2DefaultHashSize }
+
+func (p *PBKDF2) GetNumParameters() int { return pbkdf2NumParameters }
+
+func (p *PBKDF2) GetDefaultHashSize() int { return pbkdf2DefaultHashSize }
+
+func (p *PBKDF2) String() string {
+	return fmt.Sprintf("algo:%s iterations:%d", hashID, p.Iterations)
+}
+
+// Version returns the PRF generation these hashes are produced against
+// (HMAC-SHA256).
+func (p *PBKDF2) Version() int { return pbkdf2Version }
+
+func (p *PBKDF2) Parameters() map[string]uint32 {
+	return map[string]uint32{"i": uint32(p.Iterations), "keylen": p.HashSize}
+}
+
+// PHCID returns the bare id; PBKDF2-HMAC-SHA256 has no mode variants to
+// disambiguate.
+func (p *PBKDF2) PHCID() string { return hashID }
+
+// PHCAliases returns nil; PBKDF2 is only ever indexed under GetID().
+func (p *PBKDF2) PHCAliases() []string { return nil }
+
+// MarshalPHC encodes salt and key as a PBKDF2 PHC string, e.g.
+// $pbkdf2$v=2$i=600000,keylen=32$<salt>$<key>.
+func (p *PBKDF2) MarshalPHC(salt, key []byte) string {
+	return hash.EncodePHC(p, p.Parameters(), salt, key)
+}
+
+// UnmarshalPHC parses a PBKDF2 PHC string produced by MarshalPHC, returning
+// an implementation configured to reproduce it plus the decoded salt and key.
+func (p *PBKDF2) UnmarshalPHC(encoded string) (hash.HashImplementation, []byte, []byte, error) {
+	algo, version, params, salt, key, err := hash.ParsePHC(encoded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if algo != hashID {
+		return nil, nil, nil, errBadParameters
+	}
+
+	if version != pbkdf2Version {
+		return nil, nil, nil, errBadParameters
+	}
+
+	nc := *p
+	nc.HashSize = uint32(len(key))
+
+	for _, pair := range strings.Split(params, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, nil, nil, errBadParameters
+		}
+
+		v, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return nil, nil, nil, errBadParameters
+		}
+
+		switch kv[0] {
+		case "i":
+			nc.Iterations = int(v)
+		case "keylen":
+			nc.HashSize = uint32(v)
+		case "k":
+			// pepper keyid; applied by the hash package, not reconfigured here.
+		default:
+			return nil, nil, nil, errBadParameters
+		}
+	}
+
+	return &nc, salt, key, nil
+}
@@ -0,0 +1,142 @@
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errUnknownPepperKey = errors.New("unknown pepper key id")
+
+// PepperKeyring holds server-side pepper keys, indexed by a small numeric
+// id so peppers can be rotated: new PHC hashes are keyed under PreferredID,
+// while VerifyHash looks up whichever id is recorded in the hash being
+// checked. A leaked database alone is then insufficient for offline
+// cracking, since the pepper never leaves the server.
+type PepperKeyring struct {
+	Keys        map[uint32][]byte
+	PreferredID uint32
+}
+
+// pepperKeyring is nil when peppering is disabled (the default).
+var pepperKeyring *PepperKeyring
+
+// pepperMu guards pepperKeyring, rotated via SetPepper/SetPepperKeyring on a
+// live service while Hash/VerifyHash/NeedsRehash concurrently read it.
+var pepperMu sync.RWMutex
+
+// SetPepper installs key as pepper id 1 and makes it preferred, the common
+// case of a single, rotatable-later pepper. Passing a nil key disables
+// peppering. For multiple peppers, use SetPepperKeyring directly.
+func SetPepper(key []byte) {
+	if key == nil {
+		SetPepperKeyring(nil)
+		return
+	}
+
+	SetPepperKeyring(&PepperKeyring{Keys: map[uint32][]byte{1: key}, PreferredID: 1})
+}
+
+// SetPepperKeyring installs ring wholesale. Pass nil to disable peppering.
+func SetPepperKeyring(ring *PepperKeyring) {
+	pepperMu.Lock()
+	defer pepperMu.Unlock()
+
+	pepperKeyring = ring
+}
+
+// pepperKey HMACs key under the keyring's preferred pepper, returning the
+// peppered key and the id it was keyed under. used is false when peppering
+// is disabled, in which case key is returned unchanged.
+func pepperKey(key []byte) (peppered []byte, keyid uint32, used bool) {
+	pepperMu.RLock()
+	ring := pepperKeyring
+	pepperMu.RUnlock()
+
+	if ring == nil {
+		return key, 0, false
+	}
+
+	secret, found := ring.Keys[ring.PreferredID]
+	if !found {
+		return key, 0, false
+	}
+
+	hm := hmac.New(sha256.New, secret)
+	hm.Write(key)
+
+	return hm.Sum(nil), ring.PreferredID, true
+}
+
+// pepperKeyByID HMACs key under the pepper registered for keyid, for
+// verifying a hash that was peppered with a (possibly rotated-away) id.
+func pepperKeyByID(key []byte, keyid uint32) ([]byte, error) {
+	pepperMu.RLock()
+	ring := pepperKeyring
+	pepperMu.RUnlock()
+
+	if ring == nil {
+		return nil, errUnknownPepperKey
+	}
+
+	secret, found := ring.Keys[keyid]
+	if !found {
+		return nil, errUnknownPepperKey
+	}
+
+	hm := hmac.New(sha256.New, secret)
+	hm.Write(key)
+
+	return hm.Sum(nil), nil
+}
+
+// currentPepperID reports the keyring's preferred id, and whether peppering
+// is enabled at all.
+func currentPepperID() (uint32, bool) {
+	pepperMu.RLock()
+	defer pepperMu.RUnlock()
+
+	if pepperKeyring == nil {
+		return 0, false
+	}
+
+	return pepperKeyring.PreferredID, true
+}
+
+// pepperParamKey is the PHC parameter name EncodePHC writes the pepper's
+// keyid under, e.g. "...$m=65536,t=3,p=2,k=1$...".
+const pepperParamKey = "k"
+
+// withPepperID returns a copy of params with the pepper keyid field set.
+func withPepperID(params map[string]uint32, keyid uint32) map[string]uint32 {
+	out := make(map[string]uint32, len(params)+1)
+	for k, v := range params {
+		out[k] = v
+	}
+	out[pepperParamKey] = keyid
+
+	return out
+}
+
+// pepperIDFromParams extracts the pepper keyid from a PHC parameter string
+// (e.g. "m=65536,t=3,p=2,k=1"), reporting false if it carries none.
+func pepperIDFromParams(paramStr string) (uint32, bool) {
+	for _, pair := range strings.Split(paramStr, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] != pepperParamKey {
+			continue
+		}
+
+		id, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		return uint32(id), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,175 @@
+package hash
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format selects the on-disk encoding that Hash produces.
+type Format int
+
+const (
+	// FormatLegacy is the historical "$algo$params$salt$hmac" encoding.
+	FormatLegacy Format = iota
+	// FormatPHC is the PHC string format:
+	// https://github.com/P-H-C/phc-string-format
+	FormatPHC
+)
+
+// HashOptions controls optional behaviour of Hash. Format is a request, not
+// a guarantee: Hash upgrades FormatLegacy to FormatPHC whenever a pepper is
+// set via SetPepper/SetPepperKeyring, since only PHC has a field to record
+// the pepper keyid.
+type HashOptions struct {
+	Format Format
+}
+
+var errBadPHCFormat = errors.New("invalid PHC hash format")
+
+// phcDecoder is implemented by HashImplementations that can parse their own
+// PHC string back into a configured implementation, salt and key.
+type phcDecoder interface {
+	UnmarshalPHC(encoded string) (HashImplementation, []byte, []byte, error)
+}
+
+// selfSalting is implemented by HashImplementations that generate and embed
+// their own random salt, ignoring the one Hash passes them (bcrypt is the
+// only current example). Their stored key can't be checked by recomputing
+// Hash(password, salt) and comparing bytes, since the embedded salt (and so
+// the output) differs on every call even for the correct password; VerifyKey
+// is used in its place by both VerifyHash and verifyPHC. Hash also skips
+// peppering these implementations' output, since an HMAC-wrapped copy can't
+// be fed back into the algorithm's own comparison routine.
+type selfSalting interface {
+	VerifyKey(password, key []byte) (bool, error)
+}
+
+// phcParamRank fixes the order EncodePHC writes well-known parameters in
+// (m,t,p for Argon2; N,r,p for scrypt), with the pepper keyid last. Names not
+// listed rank 0 and come first, in alphabetical order.
+var phcParamRank = map[string]int{"m": 1, "t": 2, "N": 3, "r": 4, "p": 5, "cost": 6, "i": 7, "keylen": 8, pepperParamKey: 9}
+
+// EncodePHC renders impl's version and params, plus salt and key, as a PHC
+// string, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>. The algorithm
+// token is impl.PHCID(), not impl.GetID(), so variant-specific ids (e.g.
+// Argon2's mode) round-trip. Callers normally pass impl.Parameters()
+// verbatim; Hash overrides it to add a pepper keyid when peppering is
+// enabled.
+func EncodePHC(impl HashImplementation, params map[string]uint32, salt, key []byte) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := phcParamRank[names[i]], phcParamRank[names[j]]
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, params[name]))
+	}
+
+	prefix := fmt.Sprintf("$%s$v=%d$%s$%s$", impl.PHCID(), impl.Version(), strings.Join(pairs, ","), base64.RawStdEncoding.EncodeToString(salt))
+
+	return prefix + base64.RawStdEncoding.EncodeToString(key)
+}
+
+// ParsePHC decodes a PHC string into its algorithm id, version, raw
+// comma-separated parameter string, salt and key.
+func ParsePHC(s string) (algo string, version int, params string, salt, key []byte, err error) {
+	parts := strings.Split(s, Separator)
+	if len(parts) != 6 || parts[0] != "" {
+		return "", 0, "", nil, nil, errBadPHCFormat
+	}
+
+	algo = parts[1]
+
+	if !strings.HasPrefix(parts[2], "v=") {
+		return "", 0, "", nil, nil, errBadPHCFormat
+	}
+	version, err = strconv.Atoi(strings.TrimPrefix(parts[2], "v="))
+	if err != nil {
+		return "", 0, "", nil, nil, errBadPHCFormat
+	}
+
+	params = parts[3]
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return "", 0, "", nil, nil, errBadPHCFormat
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return "", 0, "", nil, nil, errBadPHCFormat
+	}
+
+	return algo, version, params, salt, key, nil
+}
+
+// isPHCEncoded reports whether encoded looks like a PHC string rather than
+// the legacy "$algo$params$salt$hmac" format.
+func isPHCEncoded(encoded string) bool {
+	parts := strings.Split(encoded, Separator)
+	return len(parts) == 6 && strings.HasPrefix(parts[2], "v=")
+}
+
+// verifyPHC verifies input against a PHC-encoded hash, dispatching to the
+// registered implementation's UnmarshalPHC so each algorithm controls its own
+// parameter parsing. Implementations satisfying selfSalting (bcrypt) are
+// checked via VerifyKey instead of the recompute-and-compare path below.
+func verifyPHC(encoded string, input []byte) (bool, error) {
+	parts := strings.Split(encoded, Separator)
+	if len(parts) != 6 {
+		return failConstantTime(errBadPHCFormat)
+	}
+
+	impl, found := lookupImpl(parts[1])
+	if !found {
+		return failConstantTime(errUnknownHashImpl)
+	}
+
+	decoder, ok := impl.(phcDecoder)
+	if !ok {
+		return failConstantTime(errUnknownHashImpl)
+	}
+
+	configured, salt, key, err := decoder.UnmarshalPHC(encoded)
+	if err != nil {
+		return failConstantTime(err)
+	}
+	defer zeroize(key)
+
+	if verifier, ok := configured.(selfSalting); ok {
+		matched, err := verifier.VerifyKey(input, key)
+		if err != nil {
+			return failConstantTime(err)
+		}
+
+		return matched, nil
+	}
+
+	_, otherKey, err := configured.Hash(input, salt)
+	if err != nil {
+		return failConstantTime(err)
+	}
+	defer zeroize(otherKey)
+
+	if keyid, peppered := pepperIDFromParams(parts[3]); peppered {
+		repeppered, err := pepperKeyByID(otherKey, keyid)
+		if err != nil {
+			return failConstantTime(err)
+		}
+		defer zeroize(repeppered)
+
+		otherKey = repeppered
+	}
+
+	return constantTimeEqual(key, otherKey), nil
+}
@@ -0,0 +1,128 @@
+package hash
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PreferredOrder lists algorithm ids from most to least preferred. Its first
+// entry is the current default algorithm; Hash and NeedsRehash consult it to
+// decide which algorithm new hashes should use and which stored hashes are
+// due an upgrade.
+//
+// Verification itself does not walk this chain: a PHC string already names
+// its own algorithm token, so VerifyHash/verifyPHC dispatch on that token
+// directly instead of trying each entry in turn. PreferredOrder only
+// answers "what's current" for Hash, NeedsRehash and Calibrate.
+var PreferredOrder = []string{DefaultAlgo}
+
+// registryMu guards HashImplementations and PreferredOrder, both mutated by
+// Register/SetDefault/Calibrate and read by every Hash/VerifyHash/
+// NeedsRehash call; a live service rotates these (e.g. adding a backend or
+// calling SetDefault) while concurrently serving logins. Direct access to
+// either var, rather than through the functions in this file, is not
+// synchronized.
+var registryMu sync.RWMutex
+
+// Register adds impl to the set of available hash implementations, keyed by
+// its GetID() and, additionally, by every token in its PHCAliases() (e.g.
+// "argon2i"/"argon2id"), so a PHC string carrying a variant-specific id can
+// still be dispatched to impl. Algorithm packages call this from an init()
+// function.
+func Register(impl HashImplementation) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	HashImplementations[impl.GetID()] = impl
+
+	for _, alias := range impl.PHCAliases() {
+		HashImplementations[alias] = impl
+	}
+}
+
+// lookupImpl returns the HashImplementation registered under id, if any.
+func lookupImpl(id string) (HashImplementation, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	impl, found := HashImplementations[id]
+	return impl, found
+}
+
+// phcMatchesImpl reports whether algo, a PHC algorithm token parsed from a
+// stored hash, refers to the same algorithm family as impl, accounting for
+// variant-specific tokens such as "argon2id" that don't equal impl.GetID().
+func phcMatchesImpl(algo string, impl HashImplementation) bool {
+	if algo == impl.GetID() {
+		return true
+	}
+
+	for _, alias := range impl.PHCAliases() {
+		if algo == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetDefault makes id, which must already be registered via Register, the
+// algorithm Hash uses for new hashes and NeedsRehash upgrades towards. It
+// moves id to the front of PreferredOrder.
+func SetDefault(id string) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, found := HashImplementations[id]; !found {
+		return errUnknownHashImpl
+	}
+
+	order := make([]string, 0, len(PreferredOrder)+1)
+	order = append(order, id)
+	for _, existing := range PreferredOrder {
+		if existing != id {
+			order = append(order, existing)
+		}
+	}
+	PreferredOrder = order
+
+	return nil
+}
+
+// currentDefault returns the algorithm id Hash and NeedsRehash treat as
+// current, i.e. the head of PreferredOrder.
+func currentDefault() string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if len(PreferredOrder) == 0 {
+		return DefaultAlgo
+	}
+
+	return PreferredOrder[0]
+}
+
+// paramsOutdated reports whether any parameter encoded in storedParams (a PHC
+// "k=v,k=v" parameter string) falls short of the matching entry in current,
+// meaning the stored hash was produced with weaker settings than today's
+// default.
+func paramsOutdated(current map[string]uint32, storedParams string) bool {
+	for _, pair := range strings.Split(storedParams, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		stored, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		if want, ok := current[kv[0]]; ok && uint32(stored) < want {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,159 @@
+// Package scrypt registers a scrypt HashImplementation with the hash package.
+package scrypt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	goscrypt "golang.org/x/crypto/scrypt"
+
+	"github.com/HazCod/go-hash/hash"
+)
+
+const (
+	hashID                = "scrypt"
+	scryptNumParameters   = 3
+	scryptDefaultN        = 1 << 15 // 32768, per golang.org/x/crypto/scrypt's recommendation
+	scryptDefaultR        = 8
+	scryptDefaultP        = 1
+	scryptDefaultHashSize = 32
+	scryptVersion         = 1
+)
+
+var errBadParameters = errors.New("malformed scrypt parameters")
+
+// Scrypt is a HashImplementation backed by golang.org/x/crypto/scrypt.
+type Scrypt struct {
+	N        int
+	R        int
+	P        int
+	HashSize uint32
+}
+
+func init() {
+	hash.Register(&Scrypt{
+		N:        scryptDefaultN,
+		R:        scryptDefaultR,
+		P:        scryptDefaultP,
+		HashSize: scryptDefaultHashSize,
+	})
+}
+
+func (s *Scrypt) encodedString() string {
+	return fmt.Sprintf("%d:%d:%d", s.N, s.R, s.P)
+}
+
+func (s *Scrypt) Hash(password, salt []byte) (string, []byte, error) {
+	key, err := goscrypt.Key(password, salt, s.N, s.R, s.P, int(s.HashSize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return s.encodedString(), key, nil
+}
+
+func (s *Scrypt) Configure(parameters string, separator string, hashSize uint32) (hash.HashImplementation, error) {
+	pars := strings.Split(parameters, separator)
+	if len(pars) < scryptNumParameters {
+		return nil, errBadParameters
+	}
+
+	n, err := strconv.Atoi(pars[0])
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	r, err := strconv.Atoi(pars[1])
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	p, err := strconv.Atoi(pars[2])
+	if err != nil {
+		return nil, errBadParameters
+	}
+
+	nc := *s
+	nc.N, nc.R, nc.P, nc.HashSize = n, r, p, hashSize
+
+	return &nc, nil
+}
+
+func (s *Scrypt) GetID() string { return hashID }
+
+func (s *Scrypt) GetDefaultLength() int { return scryptDefaultHashSize }
+
+func (s *Scrypt) GetNumParameters() int { return scryptNumParameters }
+
+func (s *Scrypt) GetDefaultHashSize() int { return scryptDefaultHashSize }
+
+func (s *Scrypt) String() string {
+	return fmt.Sprintf("algo:%s N:%d r:%d p:%d", hashID, s.N, s.R, s.P)
+}
+
+func (s *Scrypt) Version() int { return scryptVersion }
+
+func (s *Scrypt) Parameters() map[string]uint32 {
+	return map[string]uint32{"N": uint32(s.N), "r": uint32(s.R), "p": uint32(s.P)}
+}
+
+// PHCID returns the bare id; scrypt has no mode variants to disambiguate.
+func (s *Scrypt) PHCID() string { return hashID }
+
+// PHCAliases returns nil; scrypt is only ever indexed under GetID().
+func (s *Scrypt) PHCAliases() []string { return nil }
+
+// MarshalPHC encodes salt and key as a scrypt PHC string, e.g.
+// $scrypt$v=1$N=32768,r=8,p=1$<salt>$<key>.
+func (s *Scrypt) MarshalPHC(salt, key []byte) string {
+	return hash.EncodePHC(s, s.Parameters(), salt, key)
+}
+
+// UnmarshalPHC parses a scrypt PHC string produced by MarshalPHC, returning
+// an implementation configured to reproduce it plus the decoded salt and key.
+func (s *Scrypt) UnmarshalPHC(encoded string) (hash.HashImplementation, []byte, []byte, error) {
+	algo, version, params, salt, key, err := hash.ParsePHC(encoded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if algo != hashID {
+		return nil, nil, nil, errBadParameters
+	}
+
+	if version != scryptVersion {
+		return nil, nil, nil, errBadParameters
+	}
+
+	nc := *s
+	nc.HashSize = uint32(len(key))
+
+	for _, pair := range strings.Split(params, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, nil, nil, errBadParameters
+		}
+
+		v, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return nil, nil, nil, errBadParameters
+		}
+
+		switch kv[0] {
+		case "N":
+			nc.N = int(v)
+		case "r":
+			nc.R = int(v)
+		case "p":
+			nc.P = int(v)
+		case "k":
+			// pepper keyid; applied by the hash package, not reconfigured here.
+		default:
+			return nil, nil, nil, errBadParameters
+		}
+	}
+
+	return &nc, salt, key, nil
+}